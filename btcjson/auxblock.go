@@ -0,0 +1,39 @@
+// Copyright (c) 2014 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcjson
+
+// CreateAuxBlockCmd defines the createauxblock JSON-RPC command.  It has no
+// parameters; the caller's address to credit the coinbase reward to is
+// supplied via config on the server, mirroring Namecoin's getauxblock.
+type CreateAuxBlockCmd struct{}
+
+// NewCreateAuxBlockCmd returns a new instance which can be used to issue a
+// createauxblock JSON-RPC command.
+func NewCreateAuxBlockCmd() *CreateAuxBlockCmd {
+	return &CreateAuxBlockCmd{}
+}
+
+// SubmitAuxBlockCmd defines the submitauxblock JSON-RPC command.
+type SubmitAuxBlockCmd struct {
+	Hash   string
+	AuxPow string
+}
+
+// NewSubmitAuxBlockCmd returns a new instance which can be used to issue a
+// submitauxblock JSON-RPC command.
+func NewSubmitAuxBlockCmd(hash, auxPow string) *SubmitAuxBlockCmd {
+	return &SubmitAuxBlockCmd{
+		Hash:   hash,
+		AuxPow: auxPow,
+	}
+}
+
+func init() {
+	// No special flags for commands in this file.
+	flags := UsageFlag(0)
+
+	MustRegisterCmd("createauxblock", (*CreateAuxBlockCmd)(nil), flags)
+	MustRegisterCmd("submitauxblock", (*SubmitAuxBlockCmd)(nil), flags)
+}
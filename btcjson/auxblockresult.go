@@ -0,0 +1,17 @@
+// Copyright (c) 2014 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package btcjson
+
+// CreateAuxBlockResult models the data returned from the createauxblock
+// command.
+type CreateAuxBlockResult struct {
+	Hash              string `json:"hash"`
+	ChainID           uint32 `json:"chainid"`
+	PreviousBlockHash string `json:"previousblockhash"`
+	CoinbaseValue     int64  `json:"coinbasevalue"`
+	Bits              string `json:"bits"`
+	Height            int32  `json:"height"`
+	Target            string `json:"_target"`
+}
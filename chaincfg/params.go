@@ -0,0 +1,49 @@
+// Copyright (c) 2014-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"math/big"
+
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+// PoWHasher computes the proof-of-work hash of a serialized block header.
+// It is declared locally rather than as wire.PoWHasher so that chaincfg
+// does not import wire, which itself imports chaincfg for *Params; any
+// wire.PoWHasher value (ScryptHasher, SHA256dHasher, ...) satisfies this
+// interface structurally and can be assigned to Params.PoWHasher directly.
+type PoWHasher interface {
+	// Hash returns the proof-of-work hash of headerBytes, the fixed
+	// 80-byte encoding of a block header.
+	Hash(headerBytes []byte) (chainhash.Hash, error)
+}
+
+// Params defines a Viacoin network by its proof-of-work and merge-mining
+// parameters, along with anything else that varies by network.
+type Params struct {
+	// PowLimit is the highest proof-of-work target a block on this
+	// network may have; it corresponds to the lowest possible mining
+	// difficulty.
+	PowLimit *big.Int
+
+	// PoWHasher selects the algorithm used to compute a block header's
+	// proof-of-work hash.  A nil PoWHasher falls back to the network's
+	// original scrypt-based algorithm; see wire.Header.PowHash.
+	PoWHasher PoWHasher
+
+	// AuxPowChainID is the merge-mining chain ID this network claims in
+	// the version field of AuxPoW-carrying block headers, distinguishing
+	// its blocks from those of other chains merge-mined by the same
+	// miner.
+	AuxPowChainID uint32
+}
+
+// MainNetParams defines the network parameters for the main Viacoin
+// network.
+var MainNetParams = Params{
+	PowLimit:      new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 224), big.NewInt(1)),
+	AuxPowChainID: 0x0120,
+}
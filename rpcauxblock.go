@@ -0,0 +1,198 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"container/list"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/viacoin/viad/blockchain"
+	"github.com/viacoin/viad/btcjson"
+	"github.com/viacoin/viad/btcutil"
+	"github.com/viacoin/viad/chaincfg"
+	"github.com/viacoin/viad/chaincfg/chainhash"
+	"github.com/viacoin/viad/wire"
+)
+
+// maxAuxBlockCandidates is the maximum number of outstanding block
+// templates createauxblock will remember.  Once exceeded, the
+// least-recently-used candidate is evicted so long-polling merge-mining
+// pools cannot grow this cache without bound.
+const maxAuxBlockCandidates = 100
+
+// auxBlockCache is an LRU of outstanding createauxblock candidates, keyed
+// by the candidate header's hash, so multiple merge-mining pools can poll
+// concurrently without clobbering each other's in-flight template.
+type auxBlockCache struct {
+	mtx   sync.Mutex
+	cap   int
+	list  *list.List
+	items map[chainhash.Hash]*list.Element
+}
+
+// auxBlockCacheEntry is the value stored in an auxBlockCache's list elements.
+type auxBlockCacheEntry struct {
+	hash  chainhash.Hash
+	block *btcutil.Block
+}
+
+func newAuxBlockCache(capacity int) *auxBlockCache {
+	return &auxBlockCache{
+		cap:   capacity,
+		list:  list.New(),
+		items: make(map[chainhash.Hash]*list.Element),
+	}
+}
+
+// add inserts or refreshes the candidate block for hash, evicting the
+// least-recently-used entry if the cache is over capacity.
+func (c *auxBlockCache) add(hash chainhash.Hash, block *btcutil.Block) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, ok := c.items[hash]; ok {
+		elem.Value.(*auxBlockCacheEntry).block = block
+		c.list.MoveToFront(elem)
+		return
+	}
+
+	elem := c.list.PushFront(&auxBlockCacheEntry{hash: hash, block: block})
+	c.items[hash] = elem
+
+	for c.list.Len() > c.cap {
+		oldest := c.list.Back()
+		if oldest == nil {
+			break
+		}
+		c.list.Remove(oldest)
+		delete(c.items, oldest.Value.(*auxBlockCacheEntry).hash)
+	}
+}
+
+// get returns the candidate block previously stored for hash, moving it to
+// the front of the LRU.
+func (c *auxBlockCache) get(hash chainhash.Hash) (*btcutil.Block, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.items[hash]
+	if !ok {
+		return nil, false
+	}
+	c.list.MoveToFront(elem)
+	return elem.Value.(*auxBlockCacheEntry).block, true
+}
+
+// auxBlockCandidates holds the outstanding templates handed out by
+// createauxblock, since merge-mining pools address candidates by hash alone
+// and may poll from a different RPC connection than the one that created
+// the template.
+var auxBlockCandidates = newAuxBlockCache(maxAuxBlockCandidates)
+
+// buildAuxBlockResult builds the createauxblock RPC result describing
+// block, a candidate at the given height.  It is pure and decoupled from
+// rpcServer so it can be exercised directly in tests.
+func buildAuxBlockResult(block *btcutil.Block, height int32) *btcjson.CreateAuxBlockResult {
+	msgBlock := block.MsgBlock()
+	coinbaseValue := msgBlock.Transactions[0].TxOut[0].Value
+
+	return &btcjson.CreateAuxBlockResult{
+		Hash:              block.Hash().String(),
+		ChainID:           msgBlock.Header.GetChainId(),
+		PreviousBlockHash: msgBlock.Header.PrevBlock.String(),
+		CoinbaseValue:     coinbaseValue,
+		Bits:              fmt.Sprintf("%08x", msgBlock.Header.Bits),
+		Height:            height,
+		Target:            fmt.Sprintf("%064x", wire.CompactToBig(msgBlock.Header.Bits)),
+	}
+}
+
+// applyAuxPow decodes auxPowBytes into block's header, validates the
+// resulting AuxPoW against params, and, only once it validates, installs
+// the new header onto block.  It is pure and decoupled from rpcServer so
+// the submitauxblock round trip can be exercised directly in tests.
+//
+// block must not be a candidate another goroutine can still observe or
+// mutate concurrently -- see cloneAuxBlockCandidate, which
+// handleSubmitAuxBlock uses to give each submission its own copy of the
+// cached candidate before calling this.
+func applyAuxPow(block *btcutil.Block, auxPowBytes []byte, params *chaincfg.Params) error {
+	header := block.MsgBlock().Header
+	if err := wire.ReadAuxpow(auxPowBytes, &header); err != nil {
+		return err
+	}
+	if err := header.CheckAuxPoW(params); err != nil {
+		return err
+	}
+	block.MsgBlock().Header = header
+	return nil
+}
+
+// cloneAuxBlockCandidate returns a copy of block whose header can be
+// mutated by applyAuxPow without racing another goroutine that concurrently
+// fetched the same cached candidate -- auxBlockCache.get hands back the one
+// *btcutil.Block it stores, and multiple pools polling and submitting the
+// same hash is a realistic race, not just a theoretical one.  Transactions
+// are shared, not deep-copied, since applyAuxPow only ever replaces the
+// header.
+func cloneAuxBlockCandidate(block *btcutil.Block) *btcutil.Block {
+	msgBlockCopy := *block.MsgBlock()
+	return btcutil.NewBlock(&msgBlockCopy)
+}
+
+// handleCreateAuxBlock implements the createauxblock command.
+func handleCreateAuxBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	template, err := s.cfg.Generator.UpdateBlockTemplate(true)
+	if err != nil {
+		return nil, rpcInternalError(err.Error(), "Could not create block template")
+	}
+
+	msgBlock := template.Block
+	msgBlock.Header.Version |= wire.BlockVersionAuxpow
+	msgBlock.Header.Version |= int32(s.cfg.ChainParams.AuxPowChainID) * wire.BlockVersionChainStart
+
+	block := btcutil.NewBlock(msgBlock)
+	auxBlockCandidates.add(*block.Hash(), block)
+
+	return buildAuxBlockResult(block, template.Height), nil
+}
+
+// handleSubmitAuxBlock implements the submitauxblock command.
+func handleSubmitAuxBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SubmitAuxBlockCmd)
+
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+
+	candidate, ok := auxBlockCandidates.get(*hash)
+	if !ok {
+		return nil, rpcInvalidError("block hash unknown, candidate may have expired")
+	}
+	block := cloneAuxBlockCandidate(candidate)
+
+	auxPowBytes, err := hex.DecodeString(c.AuxPow)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.AuxPow)
+	}
+
+	if err := applyAuxPow(block, auxPowBytes, s.cfg.ChainParams); err != nil {
+		return nil, rpcInternalError(err.Error(), "Aux pow is not valid")
+	}
+
+	if _, err := s.cfg.SyncMgr.SubmitBlock(block, blockchain.BFNone); err != nil {
+		return nil, rpcInternalError(err.Error(), "Could not submit aux block")
+	}
+
+	return true, nil
+}
+
+func init() {
+	rpcHandlers["createauxblock"] = handleCreateAuxBlock
+	rpcHandlers["submitauxblock"] = handleSubmitAuxBlock
+}
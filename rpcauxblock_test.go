@@ -0,0 +1,235 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/viacoin/viad/btcutil"
+	"github.com/viacoin/viad/chaincfg"
+	"github.com/viacoin/viad/chaincfg/chainhash"
+	"github.com/viacoin/viad/wire"
+)
+
+// regTestPowLimitBits is the easiest difficulty target, the one regtest
+// uses: about half of all scrypt hashes meet it, so buildTestAuxPow finds a
+// passing nonce for its synthetic parent header within a few tries.
+const regTestPowLimitBits = 0x207fffff
+
+// regTestParams accepts regTestPowLimitBits as a required difficulty: its
+// PowLimit is exactly the target those bits encode, whereas
+// chaincfg.MainNetParams.PowLimit is far below it.
+var regTestParams = &chaincfg.Params{PowLimit: wire.CompactToBig(regTestPowLimitBits)}
+
+func newTestAuxBlock(nonce uint32) *btcutil.Block {
+	msgBlock := wire.MsgBlock{
+		Header: wire.BlockHeader{Header: wire.Header{Nonce: nonce}},
+	}
+	return btcutil.NewBlock(&msgBlock)
+}
+
+// newTestAuxBlockCandidate builds a candidate block, with a single coinbase
+// transaction, whose header carries chainID and the AuxPoW version bit the
+// way handleCreateAuxBlock leaves one for a pool to merge-mine against.
+func newTestAuxBlockCandidate(chainID uint32) *btcutil.Block {
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 0xffffffff},
+		SignatureScript:  []byte{0x51},
+		Sequence:         0xffffffff,
+	})
+	coinbase.AddTxOut(&wire.TxOut{Value: 5000000000, PkScript: []byte{}})
+
+	msgBlock := wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Header: wire.Header{
+				Version: int32(wire.BlockVersionAuxpow) | int32(chainID)*wire.BlockVersionChainStart,
+				Bits:    regTestPowLimitBits,
+			},
+		},
+		Transactions: []*wire.MsgTx{coinbase},
+	}
+	return btcutil.NewBlock(&msgBlock)
+}
+
+// buildTestAuxPow constructs a serialized AuxPoW, in the legacy
+// commitment format, proving merged-mining work for childHash in a parent
+// block whose chain ID differs from childChainID.
+func buildTestAuxPow(t *testing.T, childHash chainhash.Hash, childChainID uint32) []byte {
+	t.Helper()
+
+	branch := wire.MerkleBranch{
+		Branch: []chainhash.Hash{chainhash.HashH([]byte("only"))},
+		Index:  0,
+	}
+	blockchainRoot := branch.Check(childHash)
+
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: wire.OutPoint{Index: 0xffffffff},
+		SignatureScript:  append([]byte{}, blockchainRoot[:]...),
+		Sequence:         0xffffffff,
+	})
+	coinbase.AddTxOut(&wire.TxOut{Value: 0, PkScript: []byte{}})
+
+	coinbaseBranch := wire.MerkleBranch{Index: 0}
+	coinbaseRoot := coinbaseBranch.Check(coinbase.TxHash())
+
+	ap := &wire.Auxpow{
+		CoinbaseTxn:      *coinbase,
+		CoinbaseBranch:   coinbaseBranch,
+		BlockchainBranch: branch,
+		ParentBlock: wire.Header{
+			Version:    int32(childChainID + 1),
+			MerkleRoot: coinbaseRoot,
+			Timestamp:  time.Unix(1, 0),
+			Bits:       regTestPowLimitBits,
+		},
+	}
+
+	// Bump the parent's nonce until its scrypt hash meets
+	// regTestPowLimitBits.
+	target := wire.CompactToBig(regTestPowLimitBits)
+	for {
+		powHash, err := ap.ParentBlock.PowHash(nil)
+		if err != nil {
+			t.Fatalf("PowHash: %v", err)
+		}
+		if wire.HashToBig(powHash).Cmp(target) <= 0 {
+			break
+		}
+		ap.ParentBlock.Nonce++
+	}
+
+	auxPowBytes, err := wire.WriteAuxpow(&wire.BlockHeader{Auxpow: ap})
+	if err != nil {
+		t.Fatalf("WriteAuxpow: %v", err)
+	}
+	return auxPowBytes
+}
+
+// TestApplyAuxPowRoundTrip exercises the full submitauxblock path end to
+// end: a candidate produced the way handleCreateAuxBlock leaves one, a
+// serialized AuxPoW proving merge-mining work for it, and applyAuxPow
+// decoding, validating, and installing it, the same as handleSubmitAuxBlock
+// does before handing the block to the sync manager.
+func TestApplyAuxPowRoundTrip(t *testing.T) {
+	params := regTestParams
+	const chainID = 5
+
+	block := newTestAuxBlockCandidate(chainID)
+	childHash := block.MsgBlock().Header.BlockHash()
+	auxPowBytes := buildTestAuxPow(t, childHash, chainID)
+
+	if err := applyAuxPow(block, auxPowBytes, params); err != nil {
+		t.Fatalf("applyAuxPow: unexpected error: %v", err)
+	}
+
+	header := block.MsgBlock().Header
+	if header.Auxpow == nil {
+		t.Fatalf("applyAuxPow: header has no AuxPoW section installed")
+	}
+	if err := header.CheckAuxPoW(params); err != nil {
+		t.Fatalf("CheckAuxPoW: block installed by applyAuxPow doesn't validate: %v", err)
+	}
+
+	result := buildAuxBlockResult(block, 42)
+	if result.ChainID != chainID {
+		t.Fatalf("buildAuxBlockResult: ChainID = %d, want %d", result.ChainID, chainID)
+	}
+	if result.CoinbaseValue != 5000000000 {
+		t.Fatalf("buildAuxBlockResult: CoinbaseValue = %d, want %d", result.CoinbaseValue, 5000000000)
+	}
+	if result.Height != 42 {
+		t.Fatalf("buildAuxBlockResult: Height = %d, want 42", result.Height)
+	}
+}
+
+// TestApplyAuxPowRejectsInvalid verifies that applyAuxPow leaves the block's
+// header untouched when the supplied AuxPoW fails validation.
+func TestApplyAuxPowRejectsInvalid(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	const chainID = 5
+
+	block := newTestAuxBlockCandidate(chainID)
+
+	// Build an AuxPoW for the wrong child hash, so its committed root
+	// won't match this block's.
+	auxPowBytes := buildTestAuxPow(t, chainhash.HashH([]byte("someone else")), chainID)
+
+	if err := applyAuxPow(block, auxPowBytes, params); err == nil {
+		t.Fatalf("applyAuxPow: expected an error for a mismatched AuxPoW")
+	}
+	if block.MsgBlock().Header.Auxpow != nil {
+		t.Fatalf("applyAuxPow: header was modified despite the validation error")
+	}
+}
+
+// TestCloneAuxBlockCandidateIsolatesHeaderMutation verifies that applying an
+// AuxPoW to a clone returned by cloneAuxBlockCandidate never mutates the
+// original candidate, so two goroutines racing to submitauxblock the same
+// cached hash -- get() hands both the same underlying *btcutil.Block --
+// cannot race on the header write applyAuxPow performs.
+func TestCloneAuxBlockCandidateIsolatesHeaderMutation(t *testing.T) {
+	params := regTestParams
+	const chainID = 5
+
+	original := newTestAuxBlockCandidate(chainID)
+	childHash := original.MsgBlock().Header.BlockHash()
+	auxPowBytes := buildTestAuxPow(t, childHash, chainID)
+
+	clone := cloneAuxBlockCandidate(original)
+	if err := applyAuxPow(clone, auxPowBytes, params); err != nil {
+		t.Fatalf("applyAuxPow: unexpected error: %v", err)
+	}
+
+	if original.MsgBlock().Header.Auxpow != nil {
+		t.Fatalf("cloneAuxBlockCandidate: mutating the clone's header also mutated the original")
+	}
+	if clone.MsgBlock().Header.Auxpow == nil {
+		t.Fatalf("applyAuxPow: clone's header has no AuxPoW section installed")
+	}
+}
+
+// TestAuxBlockCacheRoundTrip verifies that a candidate added to the cache
+// under createauxblock's key can be retrieved again by submitauxblock, and
+// that it is evicted once more candidates than the configured capacity have
+// been added.
+func TestAuxBlockCacheRoundTrip(t *testing.T) {
+	cache := newAuxBlockCache(2)
+
+	block1 := newTestAuxBlock(1)
+	hash1 := block1.Hash()
+	cache.add(*hash1, block1)
+
+	got, ok := cache.get(*hash1)
+	if !ok {
+		t.Fatalf("get: candidate %s not found", hash1)
+	}
+	if got != block1 {
+		t.Fatalf("get: returned wrong block for %s", hash1)
+	}
+
+	block2 := newTestAuxBlock(2)
+	hash2 := block2.Hash()
+	cache.add(*hash2, block2)
+
+	block3 := newTestAuxBlock(3)
+	hash3 := block3.Hash()
+	cache.add(*hash3, block3)
+
+	// hash1 should have been evicted as the least-recently-used entry
+	// once the cache grew past its capacity of 2.
+	if _, ok := cache.get(*hash1); ok {
+		t.Fatalf("get: candidate %s should have been evicted", hash1)
+	}
+	if _, ok := cache.get(*hash2); !ok {
+		t.Fatalf("get: candidate %s should still be present", hash2)
+	}
+	if _, ok := cache.get(*hash3); !ok {
+		t.Fatalf("get: candidate %s should still be present", hash3)
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/viacoin/viad/chaincfg"
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+// AuxPowError identifies an error in merged-mining (AuxPoW) validation.  It
+// satisfies the error interface and carries a human-readable description of
+// the rule that was violated so callers can surface it directly.
+type AuxPowError string
+
+// Error satisfies the error interface.
+func (e AuxPowError) Error() string {
+	return string(e)
+}
+
+// Errors returned by Auxpow.Check and BlockHeader.CheckAuxPoW.
+const (
+	// ErrAuxPowParentChainID indicates the parent block claims the same
+	// chain ID as the child, which would allow a block to merge-mine
+	// itself.
+	ErrAuxPowParentChainID = AuxPowError("aux pow parent has our chain ID")
+
+	// ErrAuxPowCoinbaseIndex indicates the coinbase branch index was
+	// non-zero, meaning the coinbase transaction was not the leftmost
+	// leaf of the parent block's merkle tree.
+	ErrAuxPowCoinbaseIndex = AuxPowError("aux pow coinbase index is not 0")
+
+	// ErrAuxPowCoinbaseRoot indicates the coinbase merkle branch does not
+	// hash up to the parent block's merkle root.
+	ErrAuxPowCoinbaseRoot = AuxPowError("aux pow coinbase root does not match parent block's merkle root")
+
+	// ErrAuxPowMergedMiningHeaderMultiple indicates more than one merged
+	// mining header tag was found in the coinbase scriptSig.
+	ErrAuxPowMergedMiningHeaderMultiple = AuxPowError("multiple merged mining headers in coinbase")
+
+	// ErrAuxPowMissingMergedMiningHeader indicates no merged mining
+	// header tag was found and the root hash did not appear at the
+	// expected position near the start of the coinbase scriptSig either.
+	ErrAuxPowMissingMergedMiningHeader = AuxPowError("missing merged mining header in coinbase")
+
+	// ErrAuxPowRootMismatch indicates the merkle root recorded in the
+	// coinbase scriptSig does not match the root computed from the
+	// blockchain branch.
+	ErrAuxPowRootMismatch = AuxPowError("aux pow merkle root incorrect")
+
+	// ErrAuxPowWrongMerkleSize indicates the merkle size encoded in the
+	// coinbase scriptSig does not match the size implied by the number
+	// of hashes in the blockchain branch.
+	ErrAuxPowWrongMerkleSize = AuxPowError("aux pow merkle branch size mismatch")
+
+	// ErrAuxPowWrongIndex indicates the blockchain branch index does not
+	// match the index derived from the coinbase nonce and chain ID.
+	ErrAuxPowWrongIndex = AuxPowError("aux pow merkle branch index mismatch")
+
+	// ErrAuxPowProofOfWork indicates the parent block's header hash does
+	// not satisfy the difficulty the child chain actually requires, or
+	// that the required difficulty itself is out of range: a target that
+	// is not positive or that exceeds params.PowLimit.
+	ErrAuxPowProofOfWork = AuxPowError("aux pow parent block does not meet required proof of work")
+)
+
+// auxPowCoinbaseScanLimit bounds the offset in the coinbase scriptSig at
+// which the merged mining root must start when no merged mining header tag
+// is present: a root starting at this offset or later is rejected.
+const auxPowCoinbaseScanLimit = 20
+
+// getMergedMiningIndex derives the expected position of the child chain
+// within the merged-mining merkle tree from the coinbase nonce and this
+// chain's ID.  It mirrors the two-round linear congruential generator used
+// by the Namecoin/Dogecoin reference implementations.
+func getMergedMiningIndex(nonce, chainID, merkleSize uint32) uint32 {
+	rand := nonce
+	rand = rand*1103515245 + 12345
+	rand += chainID
+	rand = rand*1103515245 + 12345
+	return rand % merkleSize
+}
+
+// Check validates that the Auxpow proves merged-mining work for childHash on
+// behalf of chainID, implementing the Namecoin/Dogecoin AuxPoW rules:
+//
+//  1. the parent block must not claim our own chain ID, which would allow a
+//     block to merge-mine itself;
+//  2. the coinbase transaction must be the leftmost leaf of the parent
+//     block's merkle tree;
+//  3. the coinbase branch must hash up to the parent block's merkle root;
+//  4. the blockchain branch must hash up to a merged-mining root that is
+//     committed to by the coinbase scriptSig, at the index implied by the
+//     coinbase nonce and chainID;
+//  5. the parent block header's hash must satisfy childBits, the
+//     difficulty target the child chain itself requires at this height --
+//     not whatever Bits the parent header happens to carry, which a
+//     submitter fully controls and so proves nothing on its own.
+func (a *Auxpow) Check(childHash chainhash.Hash, chainID uint32, childBits uint32, params *chaincfg.Params) error {
+	if a.ParentBlock.GetChainId() == chainID {
+		return ErrAuxPowParentChainID
+	}
+
+	if a.CoinbaseBranch.Index != 0 {
+		return ErrAuxPowCoinbaseIndex
+	}
+
+	coinbaseRoot := a.CoinbaseBranch.Check(a.CoinbaseTxn.TxHash())
+	if coinbaseRoot != a.ParentBlock.MerkleRoot {
+		return ErrAuxPowCoinbaseRoot
+	}
+
+	blockchainRoot := a.BlockchainBranch.Check(childHash)
+
+	if len(a.CoinbaseTxn.TxIn) == 0 {
+		return ErrAuxPowMissingMergedMiningHeader
+	}
+	scriptSig := a.CoinbaseTxn.TxIn[0].SignatureScript
+
+	headerIdx := bytes.Index(scriptSig, MergedMiningHeader[:])
+	if headerIdx != -1 {
+		if bytes.LastIndex(scriptSig, MergedMiningHeader[:]) != headerIdx {
+			return ErrAuxPowMergedMiningHeaderMultiple
+		}
+
+		rootPos := headerIdx + len(MergedMiningHeader)
+		sizePos := rootPos + chainhash.HashSize
+		noncePos := sizePos + 4
+		if noncePos+4 > len(scriptSig) {
+			return ErrAuxPowMissingMergedMiningHeader
+		}
+
+		var rootHash chainhash.Hash
+		copy(rootHash[:], scriptSig[rootPos:sizePos])
+		if rootHash != blockchainRoot {
+			return ErrAuxPowRootMismatch
+		}
+
+		merkleSize := binary.LittleEndian.Uint32(scriptSig[sizePos:noncePos])
+		if merkleSize != uint32(1)<<uint(len(a.BlockchainBranch.Branch)) {
+			return ErrAuxPowWrongMerkleSize
+		}
+
+		nonce := binary.LittleEndian.Uint32(scriptSig[noncePos : noncePos+4])
+		expectedIndex := getMergedMiningIndex(nonce, chainID, merkleSize)
+		if uint32(a.BlockchainBranch.Index) != expectedIndex {
+			return ErrAuxPowWrongIndex
+		}
+	} else {
+		// No merged mining header tag: legacy miners instead commit to the
+		// root by starting it within the first auxPowCoinbaseScanLimit
+		// bytes of scriptSig.  The needle is 32 bytes, so the haystack
+		// searched must cover more than just those leading bytes -- only
+		// the match's starting offset is required to fall within the
+		// limit.
+		idx := bytes.Index(scriptSig, blockchainRoot[:])
+		if idx == -1 || idx >= auxPowCoinbaseScanLimit {
+			return ErrAuxPowMissingMergedMiningHeader
+		}
+	}
+
+	return checkAuxPowProofOfWork(&a.ParentBlock, childBits, params)
+}
+
+// checkAuxPowProofOfWork verifies that the parent block header's scrypt
+// hash satisfies bits, the difficulty the child chain requires -- not
+// whatever Bits value the parent header itself carries, which is under
+// the submitter's control and would make the check vacuous.  The parent is
+// always hashed with ScryptHasher, never params.PoWHasher: the parent is a
+// block on a foreign, merge-mined-from chain, so its hash algorithm is a
+// property of that chain's history, not of params, which describes the
+// child chain checking this AuxPoW.
+func checkAuxPowProofOfWork(header *Header, bits uint32, params *chaincfg.Params) error {
+	target := CompactToBig(bits)
+
+	if target.Sign() <= 0 || (params != nil && target.Cmp(params.PowLimit) > 0) {
+		return ErrAuxPowProofOfWork
+	}
+
+	powHash, err := powHashWithHasher(header, ScryptHasher)
+	if err != nil {
+		return err
+	}
+
+	hashNum := HashToBig(powHash)
+	if hashNum.Cmp(target) > 0 {
+		return ErrAuxPowProofOfWork
+	}
+
+	return nil
+}
+
+// CompactToBig converts a compact representation of a target difficulty,
+// as used in the Bits field, to a big.Int.  It is a copy of the well-known
+// btcd blockchain.CompactToBig helper, duplicated here since wire must not
+// import the blockchain package.
+func CompactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := compact >> 24
+
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(uint(exponent)-3))
+	}
+
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+
+	return bn
+}
+
+// HashToBig converts a chainhash.Hash into a big.Int treated as a
+// little-endian unsigned integer, matching btcd's blockchain.HashToBig
+// helper.
+func HashToBig(hash *chainhash.Hash) *big.Int {
+	buf := *hash
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+
+	return new(big.Int).SetBytes(buf[:])
+}
+
+// ReadAuxpow deserializes the AuxPoW-section encoding of data (the same
+// format written by writeAuxpow/BtcEncode for an AuxPoW header) into bh,
+// without requiring a caller-provided io.Reader.  It is a thin wrapper
+// around readAuxpow for use by packages, such as the RPC server, that
+// receive an AuxPoW blob out-of-band (e.g. hex-encoded from
+// submitauxblock) rather than as part of a full header read.
+func ReadAuxpow(data []byte, bh *BlockHeader) error {
+	bh.Version |= BlockVersionAuxpow
+	return readAuxpow(bytes.NewReader(data), 0, bh)
+}
+
+// WriteAuxpow serializes bh's AuxPoW section in the same format ReadAuxpow
+// expects, without requiring a caller-provided io.Writer.  It is the
+// counterpart to ReadAuxpow, for packages that need to produce an
+// out-of-band AuxPoW blob, such as tests exercising the submitauxblock
+// round trip.
+func WriteAuxpow(bh *BlockHeader) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeAuxpow(&buf, 0, bh); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CheckAuxPoW validates the header's AuxPoW proof against this chain's ID,
+// this header's own required difficulty (Bits), and params, returning an
+// error describing the first rule violated.  The header must have the
+// AuxPoW version bit set and carry a non-nil Auxpow.
+func (h *BlockHeader) CheckAuxPoW(params *chaincfg.Params) error {
+	if !h.IsAuxpow() || h.Auxpow == nil {
+		return ErrAuxPowMissingMergedMiningHeader
+	}
+
+	return h.Auxpow.Check(h.BlockHash(), h.GetChainId(), h.Bits, params)
+}
@@ -0,0 +1,323 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/viacoin/viad/chaincfg"
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+// regTestPowLimitBits is the easiest difficulty target, the one regtest
+// uses: about half of all scrypt hashes meet it, so mineParent finds a
+// passing nonce for the synthetic parent headers built below within a few
+// tries.
+//
+// These fixtures are hand-built rather than captured mainnet AuxPoW
+// headers: this checkout doesn't carry raw block data to source them from.
+// They're constructed to exercise the same rules real headers would --
+// coinbase branch, blockchain branch, chain-ID, and legacy scriptSig
+// commitment checks -- so swapping in real fixtures later should mean
+// adding cases here, not restructuring how they're built.
+const regTestPowLimitBits = 0x207fffff
+
+// regTestParams accepts regTestPowLimitBits as a required difficulty: its
+// PowLimit is exactly the target those bits encode, whereas
+// MainNetParams.PowLimit is far below it.
+var regTestParams = &chaincfg.Params{PowLimit: CompactToBig(regTestPowLimitBits)}
+
+// mineParent bumps parent's Nonce until its scrypt hash meets
+// regTestPowLimitBits.
+func mineParent(t *testing.T, parent *Header) {
+	t.Helper()
+
+	target := CompactToBig(regTestPowLimitBits)
+	for {
+		powHash, err := powHashWithHasher(parent, ScryptHasher)
+		if err != nil {
+			t.Fatalf("powHashWithHasher: %v", err)
+		}
+		if HashToBig(powHash).Cmp(target) <= 0 {
+			return
+		}
+		parent.Nonce++
+	}
+}
+
+// buildValidAuxpow constructs a self-consistent Auxpow proving merged-mining
+// work for childHash on behalf of childChainID.  Its coinbase scriptSig
+// carries the MergedMiningHeader tag, the root, a merkle size of 4 and a
+// nonce of 42.
+func buildValidAuxpow(t *testing.T, childHash chainhash.Hash, childChainID uint32) *Auxpow {
+	t.Helper()
+
+	const branchLen = 2
+	const nonce = 42
+
+	merkleSize := uint32(1) << branchLen
+	index := getMergedMiningIndex(nonce, childChainID, merkleSize)
+
+	// Build a blockchain branch and walk it forward from childHash to
+	// learn the root it commits to; Auxpow.Check recomputes the root with
+	// the same MerkleBranch.Check over the same branch and index, so the
+	// two agree.
+	branch := MerkleBranch{
+		Branch: []chainhash.Hash{
+			chainhash.HashH([]byte("left")),
+			chainhash.HashH([]byte("right")),
+		},
+		Index: int32(index),
+	}
+	blockchainRoot := branch.Check(childHash)
+
+	scriptSig := make([]byte, 0, 48)
+	scriptSig = append(scriptSig, MergedMiningHeader[:]...)
+	scriptSig = append(scriptSig, blockchainRoot[:]...)
+	var sizeBuf, nonceBuf [4]byte
+	binary.LittleEndian.PutUint32(sizeBuf[:], merkleSize)
+	binary.LittleEndian.PutUint32(nonceBuf[:], nonce)
+	scriptSig = append(scriptSig, sizeBuf[:]...)
+	scriptSig = append(scriptSig, nonceBuf[:]...)
+
+	coinbase := NewMsgTx(1)
+	coinbase.AddTxIn(&TxIn{
+		PreviousOutPoint: OutPoint{Index: 0xffffffff},
+		SignatureScript:  scriptSig,
+		Sequence:         0xffffffff,
+	})
+	coinbase.AddTxOut(&TxOut{Value: 0, PkScript: []byte{}})
+
+	coinbaseBranch := MerkleBranch{Index: 0}
+	coinbaseRoot := coinbaseBranch.Check(coinbase.TxHash())
+
+	parent := Header{
+		Version:    int32(childChainID + 1), // any chain ID other than childChainID
+		MerkleRoot: coinbaseRoot,
+		Timestamp:  time.Unix(1, 0),
+		Bits:       regTestPowLimitBits,
+	}
+	mineParent(t, &parent)
+
+	return &Auxpow{
+		CoinbaseTxn:      *coinbase,
+		CoinbaseBranch:   coinbaseBranch,
+		BlockchainBranch: branch,
+		ParentBlock:      parent,
+	}
+}
+
+// buildValidAuxpowLegacy is like buildValidAuxpow but commits to the
+// blockchain merkle root the legacy way: as raw bytes at the very start of
+// the coinbase scriptSig, with no MergedMiningHeader tag, size, or nonce.
+func buildValidAuxpowLegacy(t *testing.T, childHash chainhash.Hash, childChainID uint32) *Auxpow {
+	t.Helper()
+
+	branch := MerkleBranch{
+		Branch: []chainhash.Hash{chainhash.HashH([]byte("only"))},
+		Index:  0,
+	}
+	blockchainRoot := branch.Check(childHash)
+
+	scriptSig := append([]byte{}, blockchainRoot[:]...)
+
+	coinbase := NewMsgTx(1)
+	coinbase.AddTxIn(&TxIn{
+		PreviousOutPoint: OutPoint{Index: 0xffffffff},
+		SignatureScript:  scriptSig,
+		Sequence:         0xffffffff,
+	})
+	coinbase.AddTxOut(&TxOut{Value: 0, PkScript: []byte{}})
+
+	coinbaseBranch := MerkleBranch{Index: 0}
+	coinbaseRoot := coinbaseBranch.Check(coinbase.TxHash())
+
+	parent := Header{
+		Version:    int32(childChainID + 1),
+		MerkleRoot: coinbaseRoot,
+		Timestamp:  time.Unix(1, 0),
+		Bits:       regTestPowLimitBits,
+	}
+	mineParent(t, &parent)
+
+	return &Auxpow{
+		CoinbaseTxn:      *coinbase,
+		CoinbaseBranch:   coinbaseBranch,
+		BlockchainBranch: branch,
+		ParentBlock:      parent,
+	}
+}
+
+func TestAuxpowCheckLegacyFormat(t *testing.T) {
+	params := regTestParams
+	const chainID = 5
+	childHash := chainhash.HashH([]byte("child"))
+
+	ap := buildValidAuxpowLegacy(t, childHash, chainID)
+	if err := ap.Check(childHash, chainID, regTestPowLimitBits, params); err != nil {
+		t.Fatalf("Check: unexpected error for legacy-format root: %v", err)
+	}
+
+	// Moving the root past auxPowCoinbaseScanLimit bytes must still be
+	// rejected: without a merged mining header, the root must start within
+	// the leading auxPowCoinbaseScanLimit bytes.
+	ap = buildValidAuxpowLegacy(t, childHash, chainID)
+	scriptSig := ap.CoinbaseTxn.TxIn[0].SignatureScript
+	padded := append(make([]byte, auxPowCoinbaseScanLimit), scriptSig...)
+	ap.CoinbaseTxn.TxIn[0].SignatureScript = padded
+	ap.CoinbaseBranch = MerkleBranch{Index: 0}
+	ap.ParentBlock.MerkleRoot = ap.CoinbaseBranch.Check(ap.CoinbaseTxn.TxHash())
+
+	if err := ap.Check(childHash, chainID, regTestPowLimitBits, params); err != ErrAuxPowMissingMergedMiningHeader {
+		t.Fatalf("Check: got error %v, want %v", err, ErrAuxPowMissingMergedMiningHeader)
+	}
+}
+
+func TestAuxpowCheck(t *testing.T) {
+	params := regTestParams
+	const chainID = 5
+	childHash := chainhash.HashH([]byte("child"))
+
+	tests := []struct {
+		name      string
+		mutate    func(ap *Auxpow)
+		childBits uint32
+		wantErr   error
+	}{
+		{
+			name:   "valid",
+			mutate: func(ap *Auxpow) {},
+		},
+		{
+			name: "parent chain ID matches ours",
+			mutate: func(ap *Auxpow) {
+				ap.ParentBlock.Version = int32(chainID) * BlockVersionChainStart
+			},
+			wantErr: ErrAuxPowParentChainID,
+		},
+		{
+			name: "coinbase branch index not 0",
+			mutate: func(ap *Auxpow) {
+				ap.CoinbaseBranch.Index = 1
+			},
+			wantErr: ErrAuxPowCoinbaseIndex,
+		},
+		{
+			name: "coinbase root mismatch",
+			mutate: func(ap *Auxpow) {
+				ap.ParentBlock.MerkleRoot = chainhash.HashH([]byte("wrong"))
+			},
+			wantErr: ErrAuxPowCoinbaseRoot,
+		},
+		{
+			name: "root mismatch in coinbase scriptSig",
+			mutate: func(ap *Auxpow) {
+				scriptSig := ap.CoinbaseTxn.TxIn[0].SignatureScript
+				corrupted := make([]byte, len(scriptSig))
+				copy(corrupted, scriptSig)
+				corrupted[len(MergedMiningHeader)] ^= 0xff
+				ap.CoinbaseTxn.TxIn[0].SignatureScript = corrupted
+				ap.CoinbaseBranch = MerkleBranch{Index: 0}
+				root := ap.CoinbaseBranch.Check(ap.CoinbaseTxn.TxHash())
+				ap.ParentBlock.MerkleRoot = root
+			},
+			wantErr: ErrAuxPowRootMismatch,
+		},
+		{
+			name: "missing merged mining header and root not near start",
+			mutate: func(ap *Auxpow) {
+				scriptSig := make([]byte, 64)
+				ap.CoinbaseTxn.TxIn[0].SignatureScript = scriptSig
+				ap.CoinbaseBranch = MerkleBranch{Index: 0}
+				root := ap.CoinbaseBranch.Check(ap.CoinbaseTxn.TxHash())
+				ap.ParentBlock.MerkleRoot = root
+			},
+			wantErr: ErrAuxPowMissingMergedMiningHeader,
+		},
+		{
+			name: "wrong blockchain branch index",
+			mutate: func(ap *Auxpow) {
+				// Change the encoded nonce so the index it implies no
+				// longer matches BlockchainBranch.Index, without touching
+				// the committed root so that check still passes first.
+				scriptSig := ap.CoinbaseTxn.TxIn[0].SignatureScript
+				corrupted := make([]byte, len(scriptSig))
+				copy(corrupted, scriptSig)
+				noncePos := len(scriptSig) - 4
+				binary.LittleEndian.PutUint32(corrupted[noncePos:], 99999)
+				ap.CoinbaseTxn.TxIn[0].SignatureScript = corrupted
+				ap.CoinbaseBranch = MerkleBranch{Index: 0}
+				root := ap.CoinbaseBranch.Check(ap.CoinbaseTxn.TxHash())
+				ap.ParentBlock.MerkleRoot = root
+			},
+			wantErr: ErrAuxPowWrongIndex,
+		},
+		{
+			name:      "parent hash doesn't meet child's required difficulty",
+			mutate:    func(ap *Auxpow) {},
+			childBits: 0x03000001, // smallest possible target
+			wantErr:   ErrAuxPowProofOfWork,
+		},
+		{
+			name: "loosening the parent's own Bits does not relax the required difficulty",
+			mutate: func(ap *Auxpow) {
+				// A submitter fully controls ParentBlock.Bits; loosening
+				// it must not let a weak parent hash pass a strict
+				// childBits requirement.
+				ap.ParentBlock.Bits = regTestPowLimitBits
+			},
+			childBits: 0x03000001, // smallest possible target
+			wantErr:   ErrAuxPowProofOfWork,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ap := buildValidAuxpow(t, childHash, chainID)
+			tc.mutate(ap)
+
+			childBits := tc.childBits
+			if childBits == 0 {
+				childBits = regTestPowLimitBits
+			}
+
+			err := ap.Check(childHash, chainID, childBits, params)
+			if tc.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Check: unexpected error: %v", err)
+				}
+				return
+			}
+			if err != tc.wantErr {
+				t.Fatalf("Check: got error %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlockHeaderCheckAuxPoW(t *testing.T) {
+	params := regTestParams
+	const chainID = 7
+
+	header := &BlockHeader{
+		Header: Header{
+			Version: int32(BlockVersionAuxpow) | int32(chainID)*BlockVersionChainStart,
+			Bits:    regTestPowLimitBits,
+		},
+	}
+	ap := buildValidAuxpow(t, header.BlockHash(), chainID)
+	header.Auxpow = ap
+
+	if err := header.CheckAuxPoW(params); err != nil {
+		t.Fatalf("CheckAuxPoW: unexpected error: %v", err)
+	}
+
+	header.Auxpow = nil
+	if err := header.CheckAuxPoW(params); err != ErrAuxPowMissingMergedMiningHeader {
+		t.Fatalf("CheckAuxPoW: got error %v, want %v", err, ErrAuxPowMissingMergedMiningHeader)
+	}
+}
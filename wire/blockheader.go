@@ -9,8 +9,6 @@ import (
 	"io"
 	"time"
 
-	"golang.org/x/crypto/scrypt"
-
 	"github.com/viacoin/viad/chaincfg/chainhash"
 )
 
@@ -29,7 +27,7 @@ type Auxpow struct {
 	ParentBlockHash chainhash.Hash
 	CoinbaseBranch MerkleBranch
 	BlockchainBranch MerkleBranch
-	ParentBlock BlockHeader
+	ParentBlock Header
 }
 
 const BlockVersionAuxpow = 1 << 8
@@ -38,65 +36,22 @@ const BlockVersionChainStart = 1 << 16
 var MergedMiningHeader = [4]byte{0xfa, 0xbe, 'm', 'm'}
 
 // BlockHeader defines information about a block and is used in the bitcoin
-// block (MsgBlock) and headers (MsgHeaders) messages.
+// block (MsgBlock) and headers (MsgHeaders) messages.  It embeds the
+// canonical 80-byte Header and layers the optional AuxPoW section on top,
+// so headers-only code paths can work with a bare Header and never pay for
+// decoding or holding the AuxPoW coinbase transaction and merkle branches.
 type BlockHeader struct {
-	// Version of the block.  This is not the same as the protocol version.
-	Version int32
-
-	// Hash of the previous block in the block chain.
-	PrevBlock chainhash.Hash
-
-	// Merkle tree reference to hash of all transactions for the block.
-	MerkleRoot chainhash.Hash
-
-	// Time the block was created.  This is, unfortunately, encoded as a
-	// uint32 on the wire and therefore is limited to 2106.
-	Timestamp time.Time
-
-	// Difficulty target for the block.
-	Bits uint32
-
-	// Nonce used to generate the block.
-	Nonce uint32
+	Header
 
 	Auxpow *Auxpow
 }
 
-// BlockHash computes the block identifier hash for the given block header.
-func (h *BlockHeader) BlockHash() chainhash.Hash {
-	// Encode the header and double sha256 everything prior to the number of
-	// transactions.  Ignore the error returns since there is no way the
-	// encode could fail except being out of memory which would cause a
-	// run-time panic.
-	buf := bytes.NewBuffer(make([]byte, 0, MaxBlockHeaderPayloadNoAuxpow))
-	_ = writeBlockHeaderNoAuxpow(buf, 0, h)
-
-	return chainhash.DoubleHashH(buf.Bytes())
-}
-
-// PoWHash returns the Viacoin scrypt hash of this block header.
-// This value is used to check the poW on block advertised network.
-func (h *BlockHeader) PowHash() (*chainhash.Hash, error) {
-	var powHash chainhash.Hash
-
-	buf := bytes.NewBuffer(make([]byte, 0, MaxBlockHeaderPayloadNoAuxpow))
-	_ = writeBlockHeaderNoAuxpow(buf, 0, h)
-
-	scryptHash, err := scrypt.Key(buf.Bytes(), buf.Bytes(), 1024, 1, 1, 32)
-	if err != nil {
-		return nil, err
-	}
-	copy(powHash[:], scryptHash)
-
-	return &powHash, nil
-}
-
-func (h *BlockHeader) IsAuxpow() bool {
-	return h.Version & BlockVersionAuxpow != 0
-}
-
-func (h *BlockHeader) GetChainId() uint32 {
-	return uint32(h.Version / BlockVersionChainStart)
+// NewBlockHeaderFromHeader returns a BlockHeader with no AuxPoW section
+// wrapping the given Header, for callers that have a pure Header (for
+// example from headers-first sync) and need the richer type to hand to an
+// API that still expects one.
+func NewBlockHeaderFromHeader(h *Header) *BlockHeader {
+	return &BlockHeader{Header: *h}
 }
 
 func (h *BlockHeader) SerializeSize() int {
@@ -152,12 +107,14 @@ func NewBlockHeader(version int32, prevHash, merkleRootHash *chainhash.Hash,
 	// Limit the timestamp to one second precision since the protocol
 	// doesn't support better.
 	return &BlockHeader{
-		Version:    version,
-		PrevBlock:  *prevHash,
-		MerkleRoot: *merkleRootHash,
-		Timestamp:  time.Unix(time.Now().Unix(), 0),
-		Bits:       bits,
-		Nonce:      nonce,
+		Header: Header{
+			Version:    version,
+			PrevBlock:  *prevHash,
+			MerkleRoot: *merkleRootHash,
+			Timestamp:  time.Unix(time.Now().Unix(), 0),
+			Bits:       bits,
+			Nonce:      nonce,
+		},
 	}
 }
 
@@ -165,8 +122,7 @@ func NewBlockHeader(version int32, prevHash, merkleRootHash *chainhash.Hash,
 // decoding block headers stored to disk, such as in a database, as opposed to
 // decoding from the wire.
 func readBlockHeader(r io.Reader, pver uint32, bh *BlockHeader) error {
-	err := readElements(r, &bh.Version, &bh.PrevBlock, &bh.MerkleRoot,
-		(*uint32Time)(&bh.Timestamp), &bh.Bits, &bh.Nonce)
+	err := readHeader(r, pver, &bh.Header)
 	if err != nil {
 		return err
 	}
@@ -224,8 +180,7 @@ func readAuxpow(r io.Reader, pver uint32, bh *BlockHeader) error {
 		return err
 	}
 
-	err = readElements(r, &ap.ParentBlock.Version, &ap.ParentBlock.PrevBlock, &ap.ParentBlock.MerkleRoot,
-		(*uint32Time)(&ap.ParentBlock.Timestamp), &ap.ParentBlock.Bits, &ap.ParentBlock.Nonce)
+	err = readHeader(r, pver, &ap.ParentBlock)
 	if err != nil {
 		return err
 	}
@@ -234,17 +189,11 @@ func readAuxpow(r io.Reader, pver uint32, bh *BlockHeader) error {
 	return nil
 }
 
-func writeBlockHeaderNoAuxpow(w io.Writer, pver uint32, bh *BlockHeader) error {
-	sec := uint32(bh.Timestamp.Unix())
-	return writeElements(w, bh.Version, &bh.PrevBlock, &bh.MerkleRoot,
-		sec, bh.Bits, bh.Nonce)
-}
-
 // writeBlockHeader writes a bitcoin block header to w.  See Serialize for
 // encoding block headers to be stored to disk, such as in a database, as
 // opposed to encoding for the wire.
 func writeBlockHeader(w io.Writer, pver uint32, bh *BlockHeader) error {
-	err := writeBlockHeaderNoAuxpow(w, pver, bh)
+	err := writeHeader(w, pver, &bh.Header)
 	if err != nil {
 		return err
 	}
@@ -303,7 +252,7 @@ func writeAuxpow(w io.Writer, pver uint32, bh *BlockHeader) error {
 		return err
 	}
 
-	return writeBlockHeaderNoAuxpow(w, pver, &bh.Auxpow.ParentBlock)
+	return writeHeader(w, pver, &bh.Auxpow.ParentBlock)
 }
 
 func (b *MerkleBranch) Check(hash chainhash.Hash) chainhash.Hash {
@@ -348,9 +297,15 @@ func (a *Auxpow) SerializeSize() int {
 		MaxBlockHeaderPayloadNoAuxpow
 }
 
+// GetBlockHeaderSize returns the on-wire size of the block header encoded
+// at the start of raw.  It is a thin wrapper around PeekBlockHeaderSize for
+// callers that already hold the header in memory; new code reading headers
+// from disk or from peers should prefer PeekBlockHeaderSize directly so the
+// AuxPoW section's hashes and transactions never need to be materialized.
 func GetBlockHeaderSize(raw []byte) int {
-	pr := bytes.NewBuffer(raw)
-	h := BlockHeader{}
-	readBlockHeader(pr, 60002, &h)
-	return h.SerializeSize()
+	size, err := PeekBlockHeaderSize(bytes.NewReader(raw))
+	if err != nil {
+		return 0
+	}
+	return size
 }
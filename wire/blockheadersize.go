@@ -0,0 +1,212 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+// CountVarInt reads a variable length integer from r, as written by
+// WriteVarInt, and returns its decoded value along with the number of bytes
+// it occupied on the wire.  Unlike ReadVarInt it is meant for callers that
+// only need to account for the integer's size on the wire, such as
+// PeekBlockHeaderSize below.
+func CountVarInt(r io.Reader) (uint64, int, error) {
+	var prefix [1]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return 0, 0, err
+	}
+
+	switch prefix[0] {
+	case 0xff:
+		var buf [8]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, 0, err
+		}
+		return binary.LittleEndian.Uint64(buf[:]), 9, nil
+
+	case 0xfe:
+		var buf [4]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, 0, err
+		}
+		return uint64(binary.LittleEndian.Uint32(buf[:])), 5, nil
+
+	case 0xfd:
+		var buf [2]byte
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return 0, 0, err
+		}
+		return uint64(binary.LittleEndian.Uint16(buf[:])), 3, nil
+
+	default:
+		return uint64(prefix[0]), 1, nil
+	}
+}
+
+// discard skips exactly n bytes from r, returning n on success, without
+// materializing their contents.
+func discard(r io.Reader, n int64) (int64, error) {
+	copied, err := io.CopyN(ioutil.Discard, r, n)
+	return copied, err
+}
+
+// peekTxSizeNoWitness reads just enough of a witness-free transaction
+// encoding from r to determine its on-wire size, discarding every field as
+// it goes.  It mirrors the field layout MsgTx.SerializeSizeStripped
+// describes, but never builds a MsgTx or any of its inputs/outputs.
+func peekTxSizeNoWitness(r io.Reader) (int, error) {
+	size := 0
+
+	// Version.
+	if _, err := discard(r, 4); err != nil {
+		return 0, err
+	}
+	size += 4
+
+	txInCount, n, err := CountVarInt(r)
+	if err != nil {
+		return 0, err
+	}
+	size += n
+
+	for i := uint64(0); i < txInCount; i++ {
+		// Previous outpoint: hash + index.
+		if _, err := discard(r, int64(chainhash.HashSize+4)); err != nil {
+			return 0, err
+		}
+		size += chainhash.HashSize + 4
+
+		scriptLen, n, err := CountVarInt(r)
+		if err != nil {
+			return 0, err
+		}
+		size += n
+
+		if _, err := discard(r, int64(scriptLen)); err != nil {
+			return 0, err
+		}
+		size += int(scriptLen)
+
+		// Sequence.
+		if _, err := discard(r, 4); err != nil {
+			return 0, err
+		}
+		size += 4
+	}
+
+	txOutCount, n, err := CountVarInt(r)
+	if err != nil {
+		return 0, err
+	}
+	size += n
+
+	for i := uint64(0); i < txOutCount; i++ {
+		// Value.
+		if _, err := discard(r, 8); err != nil {
+			return 0, err
+		}
+		size += 8
+
+		scriptLen, n, err := CountVarInt(r)
+		if err != nil {
+			return 0, err
+		}
+		size += n
+
+		if _, err := discard(r, int64(scriptLen)); err != nil {
+			return 0, err
+		}
+		size += int(scriptLen)
+	}
+
+	// LockTime.
+	if _, err := discard(r, 4); err != nil {
+		return 0, err
+	}
+	size += 4
+
+	return size, nil
+}
+
+// peekMerkleBranchSize reads just enough of a MerkleBranch encoding from r
+// to determine its on-wire size, discarding the branch hashes as it goes.
+func peekMerkleBranchSize(r io.Reader) (int, error) {
+	size := 0
+
+	count, n, err := CountVarInt(r)
+	if err != nil {
+		return 0, err
+	}
+	size += n
+
+	if _, err := discard(r, int64(count)*chainhash.HashSize); err != nil {
+		return 0, err
+	}
+	size += int(count) * chainhash.HashSize
+
+	// Index.
+	if _, err := discard(r, 4); err != nil {
+		return 0, err
+	}
+	size += 4
+
+	return size, nil
+}
+
+// PeekBlockHeaderSize reads only as much of r as is required to determine
+// the on-wire size of the next block header, without materializing any of
+// the AuxPoW section's hashes or transactions.  This makes it suitable for
+// scanning large header streams, from disk or from peers, where fully
+// deserializing each header only to discard it is a significant hot-path
+// cost.
+func PeekBlockHeaderSize(r io.Reader) (int, error) {
+	var buf [MaxBlockHeaderPayloadNoAuxpow]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, err
+	}
+	size := MaxBlockHeaderPayloadNoAuxpow
+
+	version := int32(binary.LittleEndian.Uint32(buf[0:4]))
+	if version&BlockVersionAuxpow == 0 {
+		return size, nil
+	}
+
+	txSize, err := peekTxSizeNoWitness(r)
+	if err != nil {
+		return 0, err
+	}
+	size += txSize
+
+	// ParentBlockHash.
+	if _, err := discard(r, chainhash.HashSize); err != nil {
+		return 0, err
+	}
+	size += chainhash.HashSize
+
+	coinbaseBranchSize, err := peekMerkleBranchSize(r)
+	if err != nil {
+		return 0, err
+	}
+	size += coinbaseBranchSize
+
+	blockchainBranchSize, err := peekMerkleBranchSize(r)
+	if err != nil {
+		return 0, err
+	}
+	size += blockchainBranchSize
+
+	// ParentBlock, encoded without its own (nested) AuxPoW section.
+	size += MaxBlockHeaderPayloadNoAuxpow
+	if _, err := discard(r, MaxBlockHeaderPayloadNoAuxpow); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+// encodedTestHeader returns the on-wire encoding of a BlockHeader, with an
+// AuxPoW section when withAuxpow is true, so PeekBlockHeaderSize can be
+// exercised against a realistic byte stream.
+func encodedTestHeader(t *testing.T, withAuxpow bool) ([]byte, int) {
+	t.Helper()
+
+	header := &BlockHeader{
+		Header: Header{
+			Version:   1,
+			Timestamp: time.Unix(1, 0),
+			Bits:      0x1d00ffff,
+			Nonce:     7,
+		},
+	}
+
+	if !withAuxpow {
+		var buf bytes.Buffer
+		if err := writeBlockHeader(&buf, 0, header); err != nil {
+			t.Fatalf("writeBlockHeader: %v", err)
+		}
+		return buf.Bytes(), MaxBlockHeaderPayloadNoAuxpow
+	}
+
+	header.Version |= BlockVersionAuxpow
+
+	coinbase := NewMsgTx(1)
+	coinbase.AddTxIn(&TxIn{
+		PreviousOutPoint: OutPoint{Index: 0xffffffff},
+		SignatureScript:  []byte{0x01, 0x02, 0x03},
+		Sequence:         0xffffffff,
+	})
+	coinbase.AddTxOut(&TxOut{Value: 50, PkScript: []byte{0x51}})
+
+	header.Auxpow = &Auxpow{
+		CoinbaseTxn: *coinbase,
+		CoinbaseBranch: MerkleBranch{
+			Branch: []chainhash.Hash{chainhash.HashH([]byte("a"))},
+			Index:  0,
+		},
+		BlockchainBranch: MerkleBranch{
+			Branch: []chainhash.Hash{
+				chainhash.HashH([]byte("b")),
+				chainhash.HashH([]byte("c")),
+			},
+			Index: 1,
+		},
+		ParentBlock: Header{
+			Version:   2,
+			Timestamp: time.Unix(2, 0),
+			Bits:      0x1d00ffff,
+			Nonce:     99,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := writeBlockHeader(&buf, 0, header); err != nil {
+		t.Fatalf("writeBlockHeader: %v", err)
+	}
+
+	return buf.Bytes(), header.SerializeSize()
+}
+
+func TestPeekBlockHeaderSize(t *testing.T) {
+	tests := []struct {
+		name       string
+		withAuxpow bool
+	}{
+		{name: "no aux pow", withAuxpow: false},
+		{name: "with aux pow", withAuxpow: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			raw, wantSize := encodedTestHeader(t, tc.withAuxpow)
+
+			got, err := PeekBlockHeaderSize(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("PeekBlockHeaderSize: %v", err)
+			}
+			if got != wantSize {
+				t.Errorf("PeekBlockHeaderSize: got %d, want %d", got, wantSize)
+			}
+			if got != len(raw) {
+				t.Errorf("PeekBlockHeaderSize: got %d, want full encoding length %d", got, len(raw))
+			}
+
+			if got := GetBlockHeaderSize(raw); got != wantSize {
+				t.Errorf("GetBlockHeaderSize: got %d, want %d", got, wantSize)
+			}
+		})
+	}
+}
+
+func BenchmarkPeekBlockHeaderSize(b *testing.B) {
+	t := &testing.T{}
+	raw, _ := encodedTestHeader(t, true)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := PeekBlockHeaderSize(bytes.NewReader(raw)); err != nil {
+			b.Fatalf("PeekBlockHeaderSize: %v", err)
+		}
+	}
+}
+
+// BenchmarkGetBlockHeaderSizeFullDecode measures sizing a header by a full
+// readBlockHeader decode followed by SerializeSize, the approach
+// GetBlockHeaderSize took before it wrapped PeekBlockHeaderSize.
+func BenchmarkGetBlockHeaderSizeFullDecode(b *testing.B) {
+	t := &testing.T{}
+	raw, _ := encodedTestHeader(t, true)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h := BlockHeader{}
+		pr := bytes.NewReader(raw)
+		if err := readBlockHeader(pr, 0, &h); err != nil {
+			b.Fatalf("readBlockHeader: %v", err)
+		}
+		_ = h.SerializeSize()
+	}
+}
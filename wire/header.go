@@ -0,0 +1,158 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/viacoin/viad/chaincfg"
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+// HeaderHasher is satisfied by both Header and BlockHeader, letting
+// headers-only code paths work against either type without caring whether
+// an AuxPoW section is present.  ReadNextHeader is the prototypical
+// producer: it hands back a bare Header, skipping any AuxPoW section
+// without decoding it, for callers like headers-first sync that only need
+// BlockHash/PowHash.
+type HeaderHasher interface {
+	BlockHash() chainhash.Hash
+	PowHash(params *chaincfg.Params) (*chainhash.Hash, error)
+}
+
+// Header is the canonical 80-byte bitcoin block header: the fields that are
+// always present and hashed to produce BlockHash/PowHash, with no knowledge
+// of the optional AuxPoW section that BlockHeader layers on top.  Code that
+// only needs to identify or order blocks, such as headers-first sync, can
+// work entirely in terms of Header and avoid ever decoding an AuxPoW
+// coinbase transaction and merkle branches.
+type Header struct {
+	// Version of the block.  This is not the same as the protocol version.
+	Version int32
+
+	// Hash of the previous block in the block chain.
+	PrevBlock chainhash.Hash
+
+	// Merkle tree reference to hash of all transactions for the block.
+	MerkleRoot chainhash.Hash
+
+	// Time the block was created.  This is, unfortunately, encoded as a
+	// uint32 on the wire and therefore is limited to 2106.
+	Timestamp time.Time
+
+	// Difficulty target for the block.
+	Bits uint32
+
+	// Nonce used to generate the block.
+	Nonce uint32
+}
+
+// BlockHash computes the block identifier hash for the given header.
+func (h *Header) BlockHash() chainhash.Hash {
+	// Encode the header and double sha256 everything prior to the number of
+	// transactions.  Ignore the error returns since there is no way the
+	// encode could fail except being out of memory which would cause a
+	// run-time panic.
+	buf := bytes.NewBuffer(make([]byte, 0, MaxBlockHeaderPayloadNoAuxpow))
+	_ = writeHeader(buf, 0, h)
+
+	return chainhash.DoubleHashH(buf.Bytes())
+}
+
+// PowHash returns the proof-of-work hash of this header, as used to check
+// the PoW on a block advertised on the network.  The algorithm is selected
+// by params.PoWHasher, falling back to the original ScryptHasher when
+// params is nil or leaves PoWHasher unset.  Recently seen headers are
+// served from an in-process cache, since re-validating the same header is
+// common during reorgs and getheaders responses.
+func (h *Header) PowHash(params *chaincfg.Params) (*chainhash.Hash, error) {
+	hasher := ScryptHasher
+	if params != nil && params.PoWHasher != nil {
+		hasher = params.PoWHasher
+	}
+
+	return powHashWithHasher(h, hasher)
+}
+
+// powHashWithHasher computes h's proof-of-work hash using hasher
+// specifically, bypassing chaincfg.Params entirely.  It exists for callers
+// that must pin a particular algorithm regardless of any params in scope,
+// such as checkAuxPowProofOfWork validating a merge-mined parent block,
+// whose hash algorithm is a property of the parent chain rather than the
+// child's params.  The cache key includes hasher, so the same header bytes
+// hashed under two different algorithms never collide in the cache.
+func powHashWithHasher(h *Header, hasher PoWHasher) (*chainhash.Hash, error) {
+	bufPtr := headerBufPool.Get().(*[]byte)
+	buf := (*bufPtr)[:0]
+	defer func() {
+		*bufPtr = buf
+		headerBufPool.Put(bufPtr)
+	}()
+
+	headerBuf := bytes.NewBuffer(buf)
+	_ = writeHeader(headerBuf, 0, h)
+
+	var key powHashCacheKey
+	copy(key.header[:], headerBuf.Bytes())
+	key.hasher = hasher
+	if hash, ok := defaultPowHashCache.get(key); ok {
+		return &hash, nil
+	}
+
+	hash, err := hasher.Hash(headerBuf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	defaultPowHashCache.add(key, hash)
+	return &hash, nil
+}
+
+// IsAuxpow reports whether the header's version bit marks it as carrying an
+// AuxPoW section.  On a bare Header this only reflects the version field;
+// BlockHeader is the type that actually carries the section.
+func (h *Header) IsAuxpow() bool {
+	return h.Version&BlockVersionAuxpow != 0
+}
+
+// GetChainId returns the merge-mining chain ID encoded in the upper bits of
+// the header's version.
+func (h *Header) GetChainId() uint32 {
+	return uint32(h.Version / BlockVersionChainStart)
+}
+
+// SerializeSize returns the number of bytes it would take to serialize the
+// header, which is always the fixed 80-byte payload.
+func (h *Header) SerializeSize() int {
+	return MaxBlockHeaderPayloadNoAuxpow
+}
+
+// Serialize encodes the header to w in the format used for long-term
+// storage, such as in a database.
+func (h *Header) Serialize(w io.Writer) error {
+	return writeHeader(w, 0, h)
+}
+
+// Deserialize decodes a header from r into the receiver, using the format
+// used for long-term storage, such as in a database.
+func (h *Header) Deserialize(r io.Reader) error {
+	return readHeader(r, 0, h)
+}
+
+// readHeader reads the fixed-size portion of a bitcoin block header from r,
+// leaving any AuxPoW section, if present, for the caller to handle.
+func readHeader(r io.Reader, pver uint32, h *Header) error {
+	return readElements(r, &h.Version, &h.PrevBlock, &h.MerkleRoot,
+		(*uint32Time)(&h.Timestamp), &h.Bits, &h.Nonce)
+}
+
+// writeHeader writes the fixed-size portion of a bitcoin block header to w.
+func writeHeader(w io.Writer, pver uint32, h *Header) error {
+	sec := uint32(h.Timestamp.Unix())
+	return writeElements(w, h.Version, &h.PrevBlock, &h.MerkleRoot,
+		sec, h.Bits, h.Nonce)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2013-2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+// Both Header and BlockHeader must satisfy HeaderHasher so headers-only
+// code can be written against the interface.
+var (
+	_ HeaderHasher = (*Header)(nil)
+	_ HeaderHasher = (*BlockHeader)(nil)
+)
+
+func TestHeaderSerializeDeserialize(t *testing.T) {
+	want := &Header{
+		Version:    1,
+		PrevBlock:  chainhash.HashH([]byte("prev")),
+		MerkleRoot: chainhash.HashH([]byte("merkle")),
+		Timestamp:  time.Unix(1555555555, 0),
+		Bits:       0x1d00ffff,
+		Nonce:      12345,
+	}
+
+	var buf bytes.Buffer
+	if err := want.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	if got := buf.Len(); got != want.SerializeSize() {
+		t.Fatalf("Serialize: wrote %d bytes, want %d", got, want.SerializeSize())
+	}
+
+	var got Header
+	if err := got.Deserialize(&buf); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+
+	if got != *want {
+		t.Fatalf("Deserialize: got %+v, want %+v", got, *want)
+	}
+}
+
+func TestBlockHeaderEmbedsHeader(t *testing.T) {
+	h := &Header{
+		Version:    1,
+		PrevBlock:  chainhash.HashH([]byte("prev")),
+		MerkleRoot: chainhash.HashH([]byte("merkle")),
+		Timestamp:  time.Unix(1, 0),
+		Bits:       0x1d00ffff,
+		Nonce:      1,
+	}
+
+	bh := NewBlockHeaderFromHeader(h)
+	if bh.Auxpow != nil {
+		t.Fatalf("NewBlockHeaderFromHeader: unexpected Auxpow section")
+	}
+	if bh.BlockHash() != h.BlockHash() {
+		t.Fatalf("BlockHash: BlockHeader and its Header disagree")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"io"
+
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+// ReadNextHeader reads a single header from r and returns it as a pure
+// Header, positioning r at the start of whatever follows (the next header,
+// in a headers stream).  If the header carries an AuxPoW section, its bytes
+// are skipped, not decoded: no MsgTx, MerkleBranch, or parent Header value
+// is ever materialized.  This is the headers-only fast path HeaderHasher
+// exists for -- headers-first sync and similar code that only needs
+// BlockHash/PowHash can use it instead of the full BlockHeader decode that
+// readBlockHeader/readAuxpow perform.
+func ReadNextHeader(r io.Reader) (*Header, error) {
+	h := &Header{}
+	if err := readHeader(r, 0, h); err != nil {
+		return nil, err
+	}
+
+	if !h.IsAuxpow() {
+		return h, nil
+	}
+
+	if _, err := peekTxSizeNoWitness(r); err != nil {
+		return nil, err
+	}
+
+	// ParentBlockHash.
+	if _, err := discard(r, chainhash.HashSize); err != nil {
+		return nil, err
+	}
+
+	if _, err := peekMerkleBranchSize(r); err != nil {
+		return nil, err
+	}
+	if _, err := peekMerkleBranchSize(r); err != nil {
+		return nil, err
+	}
+
+	// ParentBlock, encoded without its own (nested) AuxPoW section.
+	if _, err := discard(r, MaxBlockHeaderPayloadNoAuxpow); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
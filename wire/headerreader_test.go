@@ -0,0 +1,58 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestReadNextHeader verifies that ReadNextHeader returns a Header
+// equivalent to the one a full BlockHeader decode would produce, without
+// materializing the AuxPoW section, and leaves the stream positioned at the
+// next header so it can be called repeatedly against a headers stream.
+func TestReadNextHeader(t *testing.T) {
+	rawAux, _ := encodedTestHeader(t, true)
+	rawPlain, wantPlain := encodedTestHeader(t, false)
+
+	var stream bytes.Buffer
+	stream.Write(rawAux)
+	stream.Write(rawPlain)
+
+	h, err := ReadNextHeader(&stream)
+	if err != nil {
+		t.Fatalf("ReadNextHeader (aux pow): %v", err)
+	}
+	if h.Version&BlockVersionAuxpow == 0 {
+		t.Fatalf("ReadNextHeader: version lost its AuxPoW bit")
+	}
+
+	var full BlockHeader
+	if err := full.Deserialize(bytes.NewReader(rawAux)); err != nil {
+		t.Fatalf("full Deserialize: %v", err)
+	}
+	if full.Auxpow == nil {
+		t.Fatalf("test fixture: expected an AuxPoW section")
+	}
+	if h.BlockHash() != full.BlockHash() {
+		t.Fatalf("ReadNextHeader: BlockHash %v, want %v", h.BlockHash(), full.BlockHash())
+	}
+
+	// The stream must now be positioned exactly at the second header.
+	if stream.Len() != len(rawPlain) {
+		t.Fatalf("ReadNextHeader: left %d trailing bytes, want %d", stream.Len(), len(rawPlain))
+	}
+
+	h2, err := ReadNextHeader(&stream)
+	if err != nil {
+		t.Fatalf("ReadNextHeader (plain): %v", err)
+	}
+	if h2.SerializeSize() != wantPlain {
+		t.Fatalf("ReadNextHeader: got size %d, want %d", h2.SerializeSize(), wantPlain)
+	}
+	if stream.Len() != 0 {
+		t.Fatalf("ReadNextHeader: %d unexpected trailing bytes", stream.Len())
+	}
+}
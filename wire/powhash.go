@@ -0,0 +1,143 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"container/list"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+// PoWHasher computes the proof-of-work hash of a serialized block header.
+// Implementations are free to use whatever algorithm a chain's consensus
+// rules require; chaincfg.Params.PoWHasher selects the one a given network
+// verifies against, and Header.PowHash dispatches through it.
+type PoWHasher interface {
+	// Hash returns the proof-of-work hash of headerBytes, the fixed
+	// 80-byte encoding of a Header as produced by writeHeader.
+	Hash(headerBytes []byte) (chainhash.Hash, error)
+}
+
+// ScryptHasher is the original Viacoin PoWHasher, computing
+// scrypt.Key(headerBytes, headerBytes, 1024, 1, 1, 32).  It is the default
+// used when a chaincfg.Params does not set PoWHasher.
+var ScryptHasher PoWHasher = scryptHasher{}
+
+// SHA256dHasher is a PoWHasher that simply double-SHA256s the header, for
+// networks or verification-only tooling that don't need scrypt's memory-hard
+// properties.
+var SHA256dHasher PoWHasher = sha256dHasher{}
+
+type scryptHasher struct{}
+
+func (scryptHasher) Hash(headerBytes []byte) (chainhash.Hash, error) {
+	var out chainhash.Hash
+
+	scryptHash, err := scrypt.Key(headerBytes, headerBytes, 1024, 1, 1, 32)
+	if err != nil {
+		return out, err
+	}
+	copy(out[:], scryptHash)
+
+	return out, nil
+}
+
+type sha256dHasher struct{}
+
+func (sha256dHasher) Hash(headerBytes []byte) (chainhash.Hash, error) {
+	return chainhash.DoubleHashH(headerBytes), nil
+}
+
+// headerBufPool pools the scratch buffers used to serialize a Header ahead
+// of hashing, so repeated PowHash calls (hot during mining and header
+// validation) don't allocate a fresh buffer every time.
+var headerBufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, MaxBlockHeaderPayloadNoAuxpow)
+		return &buf
+	},
+}
+
+// powHashCacheSize bounds the number of recent header hashes remembered by
+// the package-level PoW hash cache.
+const powHashCacheSize = 512
+
+// powHashCacheKey keys the PoW hash cache on both the serialized header and
+// the hasher that would produce its hash, since the same header bytes hash
+// differently under different algorithms once PoWHasher is pluggable per
+// chaincfg.Params.  ScryptHasher and SHA256dHasher are both empty structs
+// wrapped in the PoWHasher interface, so comparing them here is safe; a
+// hasher from chaincfg.Params whose dynamic type is not comparable (one
+// holding a slice, map or func) makes the cache lookup panic.
+type powHashCacheKey struct {
+	header [MaxBlockHeaderPayloadNoAuxpow]byte
+	hasher PoWHasher
+}
+
+// powHashCache is an LRU mapping serialized headers to their already-computed
+// PoW hash, so re-validating the same header, common during reorgs and
+// getheaders responses, is O(1) instead of repeating a scrypt search.
+type powHashCache struct {
+	mtx   sync.Mutex
+	cap   int
+	list  *list.List
+	items map[powHashCacheKey]*list.Element
+}
+
+type powHashCacheEntry struct {
+	key  powHashCacheKey
+	hash chainhash.Hash
+}
+
+func newPowHashCache(capacity int) *powHashCache {
+	return &powHashCache{
+		cap:   capacity,
+		list:  list.New(),
+		items: make(map[powHashCacheKey]*list.Element),
+	}
+}
+
+func (c *powHashCache) get(key powHashCacheKey) (chainhash.Hash, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return chainhash.Hash{}, false
+	}
+	c.list.MoveToFront(elem)
+	return elem.Value.(*powHashCacheEntry).hash, true
+}
+
+func (c *powHashCache) add(key powHashCacheKey, hash chainhash.Hash) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.list.MoveToFront(elem)
+		return
+	}
+
+	elem := c.list.PushFront(&powHashCacheEntry{key: key, hash: hash})
+	c.items[key] = elem
+
+	for c.list.Len() > c.cap {
+		oldest := c.list.Back()
+		if oldest == nil {
+			break
+		}
+		c.list.Remove(oldest)
+		delete(c.items, oldest.Value.(*powHashCacheEntry).key)
+	}
+}
+
+// defaultPowHashCache is shared by every PowHash call in the process; a
+// single cache is sufficient since the key encodes both the full header
+// and the hasher used, so entries produced under different algorithms
+// never collide.
+var defaultPowHashCache = newPowHashCache(powHashCacheSize)
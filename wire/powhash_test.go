@@ -0,0 +1,113 @@
+// Copyright (c) 2016 The btcsuite developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/viacoin/viad/chaincfg"
+	"github.com/viacoin/viad/chaincfg/chainhash"
+)
+
+func testHeader() *Header {
+	return &Header{
+		Version:    1,
+		PrevBlock:  chainhash.HashH([]byte("prev")),
+		MerkleRoot: chainhash.HashH([]byte("merkle")),
+		Timestamp:  time.Unix(1, 0),
+		Bits:       0x1d00ffff,
+		Nonce:      7,
+	}
+}
+
+func TestPowHashSelectsHasherFromParams(t *testing.T) {
+	h := testHeader()
+
+	scryptHash, err := h.PowHash(nil)
+	if err != nil {
+		t.Fatalf("PowHash(nil): %v", err)
+	}
+
+	params := &chaincfg.Params{PoWHasher: SHA256dHasher}
+	sha256dHash, err := h.PowHash(params)
+	if err != nil {
+		t.Fatalf("PowHash(sha256d): %v", err)
+	}
+
+	if *scryptHash == *sha256dHash {
+		t.Fatalf("PowHash: scrypt and sha256d hashers produced the same hash")
+	}
+
+	want, err := SHA256dHasher.Hash(mustHeaderBytes(t, h))
+	if err != nil {
+		t.Fatalf("SHA256dHasher.Hash: %v", err)
+	}
+	if *sha256dHash != want {
+		t.Fatalf("PowHash: got %v, want %v", *sha256dHash, want)
+	}
+}
+
+func TestPowHashCache(t *testing.T) {
+	h := testHeader()
+
+	// Two calls against the identical header under the same hasher must
+	// be served from the cache and agree.
+	first, err := h.PowHash(nil)
+	if err != nil {
+		t.Fatalf("PowHash: %v", err)
+	}
+	firstAgain, err := h.PowHash(nil)
+	if err != nil {
+		t.Fatalf("PowHash: %v", err)
+	}
+	if *first != *firstAgain {
+		t.Fatalf("PowHash: repeated call under the same hasher returned %v, want %v", firstAgain, first)
+	}
+
+	// The same header bytes under a different hasher must not be served
+	// from the first hasher's cache entry: the cache key must include
+	// hasher identity, not just the header encoding.
+	params := &chaincfg.Params{PoWHasher: SHA256dHasher}
+	second, err := h.PowHash(params)
+	if err != nil {
+		t.Fatalf("PowHash: %v", err)
+	}
+	if *first == *second {
+		t.Fatalf("PowHash: scrypt and sha256d results collided at %v", first)
+	}
+
+	want, err := SHA256dHasher.Hash(mustHeaderBytes(t, h))
+	if err != nil {
+		t.Fatalf("SHA256dHasher.Hash: %v", err)
+	}
+	if *second != want {
+		t.Fatalf("PowHash: cached sha256d result %v, want %v", second, want)
+	}
+}
+
+func mustHeaderBytes(t *testing.T, h *Header) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := writeHeader(&buf, 0, h); err != nil {
+		t.Fatalf("writeHeader: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func BenchmarkPowHash(b *testing.B) {
+	h := testHeader()
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h.Nonce = uint32(i)
+		if _, err := h.PowHash(nil); err != nil {
+			b.Fatalf("PowHash: %v", err)
+		}
+	}
+}